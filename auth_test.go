@@ -0,0 +1,84 @@
+package httpman
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSigV4AuthenticatorSignsCanonicalRequest(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://examplehost.com/resource?b=2&a=1", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	auth := SigV4Authenticator{
+		AccessKeyID:     "AKIDTEST",
+		SecretAccessKey: "secret123",
+		Region:          "us-east-1",
+		Service:         "execute-api",
+		Now:             func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) },
+	}
+	if err := auth.Apply(req); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	const wantDate = "20200101T000000Z"
+	if got := req.Header.Get("X-Amz-Date"); got != wantDate {
+		t.Fatalf("X-Amz-Date = %q, want %q", got, wantDate)
+	}
+
+	const emptyBodyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	if got := req.Header.Get("X-Amz-Content-Sha256"); got != emptyBodyHash {
+		t.Fatalf("X-Amz-Content-Sha256 = %q, want %q", got, emptyBodyHash)
+	}
+
+	// Computed independently (Python hmac/hashlib) following the same
+	// canonical-request, string-to-sign, and signing-key derivation steps.
+	const want = "AWS4-HMAC-SHA256 Credential=AKIDTEST/20200101/us-east-1/execute-api/aws4_request, " +
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=71b2331c267a7204494d179bf33020901ef70e1e004e7a3ad4a6d222e918b2b4"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("Authorization = %q, want %q", got, want)
+	}
+}
+
+func TestSigV4CanonicalQueryIsSortedAndEscaped(t *testing.T) {
+	query := sigV4CanonicalQuery(map[string][]string{
+		"b": {"2"},
+		"a": {"1"},
+		"c": {"x y"},
+	})
+	const want = "a=1&b=2&c=x%20y"
+	if query != want {
+		t.Fatalf("sigV4CanonicalQuery = %q, want %q", query, want)
+	}
+}
+
+func TestHMACAuthenticatorCoversHostAndQuery(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	auth := HMACAuthenticator{KeyID: "key1", Secret: "secret", Now: func() time.Time { return now }}
+
+	sign := func(rawURL string) string {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+		if err := auth.Apply(req); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+		return req.Header.Get("Authorization")
+	}
+
+	base := sign("http://example.com/resource?a=1")
+	differentQuery := sign("http://example.com/resource?a=2")
+	differentHost := sign("http://other.com/resource?a=1")
+
+	if base == differentQuery {
+		t.Fatal("expected the signature to change when the query string changes")
+	}
+	if base == differentHost {
+		t.Fatal("expected the signature to change when the host changes")
+	}
+}
@@ -3,7 +3,7 @@ package httpman
 import (
 	"bytes"
 	"io"
-	"strings"
+	"sync"
 
 	goquery "github.com/google/go-querystring/query"
 	jsoniter "github.com/json-iterator/go"
@@ -38,6 +38,26 @@ func (p *bodyProvider) Body() (io.Reader, error) {
 	return p.body, nil
 }
 
+// bufferPool pools *bytes.Buffer instances used to encode JSON and form
+// bodies, avoiding an allocation on every Request.Do. A retry middleware
+// replays the encoded bytes via req.GetBody without encoding them again.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty *bytes.Buffer from bufferPool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns buf to bufferPool for reuse. Callers must not read from
+// or retain buf after calling putBuffer.
+func putBuffer(buf *bytes.Buffer) {
+	bufferPool.Put(buf)
+}
+
 // jsonBodyProvider encodes a JSON tagged struct value as a Body for requests.
 type jsonBodyProvider struct {
 	payload interface{}
@@ -48,9 +68,9 @@ func (p *jsonBodyProvider) ContentType() string {
 }
 
 func (p *jsonBodyProvider) Body() (io.Reader, error) {
-	buf := &bytes.Buffer{}
-	err := json.NewEncoder(buf).Encode(p.payload)
-	if err != nil {
+	buf := getBuffer()
+	if err := json.NewEncoder(buf).Encode(p.payload); err != nil {
+		putBuffer(buf)
 		return nil, err
 	}
 	return buf, nil
@@ -70,5 +90,7 @@ func (p *formBodyProvider) Body() (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
-	return strings.NewReader(values.Encode()), nil
+	buf := getBuffer()
+	buf.WriteString(values.Encode())
+	return buf, nil
 }
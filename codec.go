@@ -0,0 +1,163 @@
+package httpman
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	msgpack "github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	xmlContentType     = "application/xml"
+	msgpackContentType = "application/msgpack"
+	protoContentType   = "application/x-protobuf"
+	problemContentType = "application/problem+json"
+)
+
+// ResponseDecoder decodes an HTTP response Body into the value pointed to by
+// v.
+type ResponseDecoder interface {
+	Decode(resp *http.Response, v interface{}) error
+}
+
+// Encoder encodes a value into an io.Reader body and advertises its
+// Content-Type, mirroring BodyProvider. The built-in encoders back BodyXML,
+// BodyMsgpack, and BodyProto; an Encoder passed to Httpman.RegisterCodec is
+// stored with its decoder, but no Request method encodes a body with it.
+type Encoder interface {
+	// ContentType returns the Content-Type of the encoded body.
+	ContentType() string
+	// Encode returns the io.Reader body produced by encoding v.
+	Encode(v interface{}) (io.Reader, error)
+}
+
+// codecBodyProvider adapts an Encoder to a BodyProvider for payload.
+type codecBodyProvider struct {
+	encoder Encoder
+	payload interface{}
+}
+
+func (p *codecBodyProvider) ContentType() string {
+	return p.encoder.ContentType()
+}
+
+func (p *codecBodyProvider) Body() (io.Reader, error) {
+	return p.encoder.Encode(p.payload)
+}
+
+// jsonDecoder is the default ResponseDecoder, decoding JSON response bodies.
+type jsonDecoder struct{}
+
+func (jsonDecoder) Decode(resp *http.Response, v interface{}) error {
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jsonEncoder encodes values as JSON, mirroring jsonBodyProvider for use
+// through the Encoder interface and the codec registry.
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string { return jsonContentType }
+
+func (jsonEncoder) Encode(v interface{}) (io.Reader, error) {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// XMLDecoder decodes XML response bodies.
+type XMLDecoder struct{}
+
+func (XMLDecoder) Decode(resp *http.Response, v interface{}) error {
+	return xml.NewDecoder(resp.Body).Decode(v)
+}
+
+// xmlEncoder encodes values as XML.
+type xmlEncoder struct{}
+
+func (xmlEncoder) ContentType() string { return xmlContentType }
+
+func (xmlEncoder) Encode(v interface{}) (io.Reader, error) {
+	buf := &bytes.Buffer{}
+	if err := xml.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// MsgpackDecoder decodes MessagePack response bodies.
+type MsgpackDecoder struct{}
+
+func (MsgpackDecoder) Decode(resp *http.Response, v interface{}) error {
+	return msgpack.NewDecoder(resp.Body).Decode(v)
+}
+
+// msgpackEncoder encodes values as MessagePack.
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) ContentType() string { return msgpackContentType }
+
+func (msgpackEncoder) Encode(v interface{}) (io.Reader, error) {
+	buf := &bytes.Buffer{}
+	if err := msgpack.NewEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// ProtoDecoder decodes protobuf response bodies into a proto.Message.
+type ProtoDecoder struct{}
+
+func (ProtoDecoder) Decode(resp *http.Response, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("httpman: ProtoDecoder requires a proto.Message, got %T", v)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(body, msg)
+}
+
+// protoEncoder encodes proto.Message values as protobuf.
+type protoEncoder struct{}
+
+func (protoEncoder) ContentType() string { return protoContentType }
+
+func (protoEncoder) Encode(v interface{}) (io.Reader, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("httpman: BodyProto requires a proto.Message, got %T", v)
+	}
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+// codec pairs the Encoder and ResponseDecoder registered for a Content-Type.
+type codec struct {
+	encoder Encoder
+	decoder ResponseDecoder
+}
+
+// defaultCodecs returns the built-in JSON, XML, MessagePack, protobuf, and
+// RFC 7807 problem+json codecs, keyed by Content-Type, that every Httpman is
+// seeded with.
+func defaultCodecs() map[string]codec {
+	return map[string]codec{
+		jsonContentType:    {encoder: jsonEncoder{}, decoder: jsonDecoder{}},
+		xmlContentType:     {encoder: xmlEncoder{}, decoder: XMLDecoder{}},
+		msgpackContentType: {encoder: msgpackEncoder{}, decoder: MsgpackDecoder{}},
+		protoContentType:   {encoder: protoEncoder{}, decoder: ProtoDecoder{}},
+		problemContentType: {encoder: jsonEncoder{}, decoder: ProblemDetailsDecoder{}},
+	}
+}
@@ -0,0 +1,50 @@
+package httpman
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// HTTPError represents an HTTP response whose status code was rejected by
+// Request.ExpectStatus or Request.ErrorOn4xx5xx. It captures the response
+// Body since resp.Body is already drained and closed by the time Do returns.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+	Header     http.Header
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("httpman: unexpected status %s", e.Status)
+}
+
+// ProblemDetails is an RFC 7807 application/problem+json error body.
+type ProblemDetails struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// ProblemDetailsDecoder decodes an RFC 7807 application/problem+json
+// response body into the value pointed to by v, typically a *ProblemDetails.
+// It is registered by default for the application/problem+json Content-Type
+// and usable directly with Request.OnStatus.
+type ProblemDetailsDecoder struct{}
+
+func (ProblemDetailsDecoder) Decode(resp *http.Response, v interface{}) error {
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// statusRange pairs an inclusive status code range with the ResponseDecoder
+// registered for it via Request.OnStatus.
+type statusRange struct {
+	min, max int
+	decoder  ResponseDecoder
+}
+
+func (sr statusRange) matches(code int) bool {
+	return code >= sr.min && code <= sr.max
+}
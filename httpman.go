@@ -1,7 +1,9 @@
 package httpman
 
 import (
+	"context"
 	"encoding/base64"
+	"mime"
 	"net/http"
 )
 
@@ -24,6 +26,17 @@ type Httpman struct {
 	queryStructs []interface{}
 	// url query map
 	queryMap map[string]string
+	// stack of client-side middleware wrapping httpClient, applied in the
+	// order they were added via Use
+	middlewares []Middleware
+	// registered Encoder/ResponseDecoder pairs, keyed by Content-Type, used
+	// to negotiate response decoding
+	codecs map[string]codec
+	// default context inherited by every Request created via NewRequest
+	ctx context.Context
+	// default Authenticator applied to every Request, unless overridden by
+	// Request.Authenticator
+	authenticator Authenticator
 }
 
 // New returns a new instance with an http DefaultClient.
@@ -34,6 +47,8 @@ func New(baseURL string) *Httpman {
 		baseURL:      baseURL,
 		queryStructs: make([]interface{}, 0),
 		queryMap:     make(map[string]string),
+		middlewares:  make([]Middleware, 0),
+		codecs:       defaultCodecs(),
 	}
 }
 
@@ -59,6 +74,64 @@ func (h *Httpman) Doer(doer Executor) *Httpman {
 	return h
 }
 
+// Middleware
+
+// Use appends a Middleware to the stack wrapping the Executor used to send
+// requests. Middlewares are applied in the order they are added: the first
+// Middleware added is the outermost layer and sees the request first.
+func (h *Httpman) Use(mw Middleware) *Httpman {
+	if mw != nil {
+		h.middlewares = append(h.middlewares, mw)
+	}
+	return h
+}
+
+// executor returns the Executor to use for sending requests: httpClient
+// wrapped by each Middleware added via Use, outermost first.
+func (h *Httpman) executor() Executor {
+	exec := h.httpClient
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		exec = h.middlewares[i](exec)
+	}
+	return exec
+}
+
+// Context
+
+// WithContext sets the default context inherited by every Request created
+// via NewRequest, unless overridden by Request.WithContext or Request.Timeout.
+func (h *Httpman) WithContext(ctx context.Context) *Httpman {
+	if ctx != nil {
+		h.ctx = ctx
+	}
+	return h
+}
+
+// Codecs
+
+// RegisterCodec registers an Encoder and ResponseDecoder pair for
+// contentType, letting Httpman negotiate response decoding by Content-Type
+// beyond the built-in JSON, XML, MessagePack, and protobuf support, e.g. to
+// plug in CBOR or gob.
+func (h *Httpman) RegisterCodec(contentType string, enc Encoder, dec ResponseDecoder) *Httpman {
+	h.codecs[contentType] = codec{encoder: enc, decoder: dec}
+	return h
+}
+
+// decoderForContentType returns the decoder registered for the Content-Type
+// header value ct, ignoring parameters such as charset.
+func (h *Httpman) decoderForContentType(ct string) (ResponseDecoder, bool) {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return nil, false
+	}
+	c, ok := h.codecs[mediaType]
+	if !ok {
+		return nil, false
+	}
+	return c.decoder, true
+}
+
 // Header
 
 // AddHeader adds the key, value pair in Headers, appending values for existing keys
@@ -82,6 +155,19 @@ func (h *Httpman) SetBasicAuth(username, password string) *Httpman {
 	return h.SetHeader("Authorization", "Basic "+basicAuth(username, password))
 }
 
+// Authenticator
+
+// Authenticator sets the default Authenticator applied to every Request
+// created via NewRequest, unless overridden by Request.Authenticator. Unlike
+// SetBasicAuth it is applied lazily in Send, so it can use the request's
+// final method, URL, and body (signers such as SigV4Authenticator and
+// HMACAuthenticator need this) and can refresh credentials over time (as
+// OAuth2Authenticator does).
+func (h *Httpman) Authenticator(a Authenticator) *Httpman {
+	h.authenticator = a
+	return h
+}
+
 // QueryStruct appends the queryStruct to the queryStructs. The value
 // pointed to by each queryStruct will be encoded as url query parameters on
 // The queryStruct argument should be a pointer to a url tagged struct.
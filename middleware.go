@@ -0,0 +1,388 @@
+package httpman
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Middleware wraps an Executor with additional behavior, forming a stack of
+// client-side middleware when composed with Httpman.Use.
+type Middleware func(Executor) Executor
+
+// executorFunc adapts a function to the Executor interface, analogous to
+// http.HandlerFunc.
+type executorFunc func(req *http.Request) (*http.Response, error)
+
+func (f executorFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// RetryOptions configures Retry.
+type RetryOptions struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	// Defaults to 3 when zero.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. Defaults to 100ms when
+	// zero.
+	BaseDelay time.Duration
+	// MaxDelay caps the computed backoff delay, before a Retry-After header
+	// overrides it. Defaults to 10s when zero.
+	MaxDelay time.Duration
+	// Retryable reports whether the response/error should be retried.
+	// Defaults to retrying on network errors and 429/5xx responses.
+	Retryable func(resp *http.Response, err error) bool
+}
+
+// Retry returns a Middleware that retries failed requests with exponential
+// backoff and jitter, honoring a Retry-After header on 429/503 responses.
+// Requests with a body are only retried when the body can be replayed: either
+// req.GetBody is set, which net/http populates automatically for the
+// *bytes.Buffer bodies used by BodyJSON and BodyForm, or via
+// Request.GetBody for a raw io.Reader passed to Body.
+func Retry(opts RetryOptions) Middleware {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = 100 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 10 * time.Second
+	}
+	if opts.Retryable == nil {
+		opts.Retryable = defaultRetryable
+	}
+	return func(next Executor) Executor {
+		return executorFunc(func(req *http.Request) (*http.Response, error) {
+			var resp *http.Response
+			var err error
+			for attempt := 0; attempt < opts.MaxAttempts; attempt++ {
+				if attempt > 0 {
+					body, bodyErr := freshBody(req)
+					if bodyErr != nil {
+						return resp, err
+					}
+					req.Body = body
+				}
+				resp, err = next.Do(req)
+				if !opts.Retryable(resp, err) || attempt == opts.MaxAttempts-1 {
+					return resp, err
+				}
+				delay := retryAfterDelay(resp)
+				if delay == 0 {
+					delay = backoffDelay(opts.BaseDelay, opts.MaxDelay, attempt)
+				}
+				if resp != nil && resp.Body != nil {
+					io.Copy(ioutil.Discard, resp.Body)
+					resp.Body.Close()
+				}
+				select {
+				case <-req.Context().Done():
+					return resp, req.Context().Err()
+				case <-time.After(delay):
+				}
+			}
+			return resp, err
+		})
+	}
+}
+
+// defaultRetryable retries network errors, 429 Too Many Requests, and 5xx
+// responses.
+func defaultRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// freshBody returns a replayable copy of req's body for a retry attempt, or
+// an error if the body was consumed and cannot be replayed.
+func freshBody(req *http.Request) (io.ReadCloser, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req.Body, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("httpman: request body is not replayable; set Request.GetBody or use BodyJSON/BodyForm")
+	}
+	return req.GetBody()
+}
+
+// retryAfterDelay parses a Retry-After header (either delay-seconds or an
+// HTTP-date) and returns the delay it specifies, or zero if absent.
+func retryAfterDelay(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	ra := resp.Header.Get("Retry-After")
+	if ra == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(ra); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if when, err := http.ParseTime(ra); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoffDelay computes an exponential backoff delay for attempt (0-based),
+// capped at max, with full jitter applied.
+func backoffDelay(base, max time.Duration, attempt int) time.Duration {
+	d := base * time.Duration(math.Pow(2, float64(attempt)))
+	if d <= 0 || d > max {
+		d = max
+	}
+	return time.Duration(mathrand.Int63n(int64(d)))
+}
+
+// RateLimiter returns a Middleware that throttles outgoing requests to rps
+// requests per second, allowing bursts up to burst, using a token bucket.
+// Requests block until a token is available or the request's context is
+// done.
+func RateLimiter(rps float64, burst int) Middleware {
+	if burst <= 0 {
+		burst = 1
+	}
+	bucket := newTokenBucket(rps, burst)
+	return func(next Executor) Executor {
+		return executorFunc(func(req *http.Request) (*http.Response, error) {
+			if err := bucket.wait(req.Context()); err != nil {
+				return nil, err
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// tokenBucket is a simple token-bucket rate limiter.
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	burst    float64
+	rps      float64
+	lastFill time.Time
+}
+
+func newTokenBucket(rps float64, burst int) *tokenBucket {
+	return &tokenBucket{tokens: float64(burst), burst: float64(burst), rps: rps, lastFill: time.Now()}
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.lastFill).Seconds()*b.rps)
+		b.lastFill = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rps * float64(time.Second))
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ErrCircuitOpen is returned when CircuitBreaker rejects a request because
+// the circuit is open.
+var ErrCircuitOpen = errors.New("httpman: circuit breaker is open")
+
+// CircuitBreakerOptions configures CircuitBreaker.
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the failure ratio, between 0 and 1, over Window
+	// requests that trips the breaker from closed to open. Defaults to 0.5.
+	FailureThreshold float64
+	// Window is the minimum number of sampled requests before the failure
+	// ratio is evaluated. Defaults to 10.
+	Window int
+	// OpenDuration is how long the breaker stays open before allowing a
+	// single half-open probe request through. Defaults to 30s.
+	OpenDuration time.Duration
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// CircuitBreaker returns a Middleware implementing a closed -> open ->
+// half-open circuit breaker: requests are counted in consecutive windows of
+// Window requests, the counts being reset at the end of each window; once the
+// failure ratio of a window reaches FailureThreshold, the breaker opens and fails fast
+// with ErrCircuitOpen for OpenDuration, then allows a single half-open probe
+// request through to decide whether to close again.
+func CircuitBreaker(opts CircuitBreakerOptions) Middleware {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = 0.5
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10
+	}
+	if opts.OpenDuration <= 0 {
+		opts.OpenDuration = 30 * time.Second
+	}
+	cb := &circuitBreaker{opts: opts}
+	return func(next Executor) Executor {
+		return executorFunc(func(req *http.Request) (*http.Response, error) {
+			if !cb.allow() {
+				return nil, ErrCircuitOpen
+			}
+			resp, err := next.Do(req)
+			cb.record(err == nil && resp != nil && resp.StatusCode < 500)
+			return resp, err
+		})
+	}
+}
+
+// circuitBreaker tracks the per-window failure ratio for CircuitBreaker.
+type circuitBreaker struct {
+	opts CircuitBreakerOptions
+
+	mu        sync.Mutex
+	state     circuitState
+	openedAt  time.Time
+	successes int
+	failures  int
+	// probeInFlight is true while a half-open probe request is outstanding,
+	// so concurrent callers are rejected until record resolves it; without
+	// it, every caller racing the open->half-open transition would see
+	// circuitHalfOpen and be let through at once.
+	probeInFlight bool
+}
+
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case circuitOpen:
+		if time.Since(cb.openedAt) < cb.opts.OpenDuration {
+			return false
+		}
+		cb.state = circuitHalfOpen
+		cb.probeInFlight = true
+		return true
+	case circuitHalfOpen:
+		if cb.probeInFlight {
+			return false
+		}
+		cb.probeInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (cb *circuitBreaker) record(ok bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == circuitHalfOpen {
+		cb.probeInFlight = false
+		if ok {
+			cb.state = circuitClosed
+			cb.successes, cb.failures = 0, 0
+		} else {
+			cb.state = circuitOpen
+			cb.openedAt = time.Now()
+		}
+		return
+	}
+	if ok {
+		cb.successes++
+	} else {
+		cb.failures++
+	}
+	if total := cb.successes + cb.failures; total >= cb.opts.Window {
+		if float64(cb.failures)/float64(total) >= cb.opts.FailureThreshold {
+			cb.state = circuitOpen
+			cb.openedAt = time.Now()
+		}
+		cb.successes, cb.failures = 0, 0
+	}
+}
+
+// Logger is implemented by *log.Logger and other compatible loggers.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// LoggingMiddleware returns a Middleware that logs the method, URL, status
+// code or error, and latency of every request via logger.
+func LoggingMiddleware(logger Logger) Middleware {
+	return func(next Executor) Executor {
+		return executorFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.Do(req)
+			if err != nil {
+				logger.Printf("httpman: %s %s failed in %s: %v", req.Method, req.URL, time.Since(start), err)
+				return resp, err
+			}
+			logger.Printf("httpman: %s %s -> %d in %s", req.Method, req.URL, resp.StatusCode, time.Since(start))
+			return resp, err
+		})
+	}
+}
+
+// TraceIDHeader is the header TracePropagator uses by default to propagate a
+// trace id.
+const TraceIDHeader = "X-Trace-Id"
+
+type traceIDContextKey struct{}
+
+// ContextWithTraceID returns a context carrying traceID for propagation by
+// TracePropagator.
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDContextKey{}, traceID)
+}
+
+// TracePropagator returns a Middleware that ensures every outgoing request
+// carries a trace id in header. If the request's context carries a trace id
+// set via ContextWithTraceID it is reused; otherwise one is minted with
+// newTraceID, which defaults to a random 16-byte hex string.
+func TracePropagator(header string, newTraceID func() string) Middleware {
+	if header == "" {
+		header = TraceIDHeader
+	}
+	if newTraceID == nil {
+		newTraceID = generateTraceID
+	}
+	return func(next Executor) Executor {
+		return executorFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(header) == "" {
+				if id, ok := req.Context().Value(traceIDContextKey{}).(string); ok {
+					req.Header.Set(header, id)
+				} else {
+					req.Header.Set(header, newTraceID())
+				}
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+func generateTraceID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,170 @@
+package httpman
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func tripBreaker(t *testing.T, cb *circuitBreaker) {
+	t.Helper()
+	cb.record(false)
+	cb.record(false)
+	if cb.state != circuitOpen {
+		t.Fatalf("expected circuit to be open after tripping the failure threshold, got state %d", cb.state)
+	}
+}
+
+func TestCircuitBreakerHalfOpenAdmitsOneProbe(t *testing.T) {
+	cb := &circuitBreaker{opts: CircuitBreakerOptions{
+		FailureThreshold: 0.5,
+		Window:           2,
+		OpenDuration:     10 * time.Millisecond,
+	}}
+	tripBreaker(t, cb)
+	time.Sleep(15 * time.Millisecond)
+
+	var admitted int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if cb.allow() {
+				atomic.AddInt32(&admitted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if admitted != 1 {
+		t.Fatalf("admitted %d concurrent requests during half-open, want exactly 1", admitted)
+	}
+}
+
+func TestCircuitBreakerClosesAfterSuccessfulProbe(t *testing.T) {
+	cb := &circuitBreaker{opts: CircuitBreakerOptions{
+		FailureThreshold: 0.5,
+		Window:           2,
+		OpenDuration:     10 * time.Millisecond,
+	}}
+	tripBreaker(t, cb)
+	time.Sleep(15 * time.Millisecond)
+
+	if !cb.allow() {
+		t.Fatal("expected the probe request to be admitted")
+	}
+	cb.record(true)
+
+	if cb.state != circuitClosed {
+		t.Fatalf("expected circuit to close after a successful probe, got state %d", cb.state)
+	}
+	if !cb.allow() {
+		t.Fatal("expected requests to be admitted once the circuit is closed")
+	}
+}
+
+func TestCircuitBreakerReopensAfterFailedProbe(t *testing.T) {
+	cb := &circuitBreaker{opts: CircuitBreakerOptions{
+		FailureThreshold: 0.5,
+		Window:           2,
+		OpenDuration:     10 * time.Millisecond,
+	}}
+	tripBreaker(t, cb)
+	time.Sleep(15 * time.Millisecond)
+
+	if !cb.allow() {
+		t.Fatal("expected the probe request to be admitted")
+	}
+	cb.record(false)
+
+	if cb.state != circuitOpen {
+		t.Fatalf("expected circuit to reopen after a failed probe, got state %d", cb.state)
+	}
+	if cb.allow() {
+		t.Fatal("expected requests to be rejected immediately after the probe reopened the circuit")
+	}
+}
+
+func TestBackoffDelayIsBounded(t *testing.T) {
+	base := 10 * time.Millisecond
+	max := 200 * time.Millisecond
+	for attempt := 0; attempt < 6; attempt++ {
+		for i := 0; i < 50; i++ {
+			d := backoffDelay(base, max, attempt)
+			if d < 0 || d > max {
+				t.Fatalf("attempt %d: backoffDelay returned %s, want within [0, %s]", attempt, d, max)
+			}
+		}
+	}
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	t.Run("delay-seconds", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{"Retry-After": []string{"2"}}}
+		if got, want := retryAfterDelay(resp), 2*time.Second; got != want {
+			t.Fatalf("retryAfterDelay = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("http-date", func(t *testing.T) {
+		when := time.Now().Add(5 * time.Second).UTC()
+		resp := &http.Response{Header: http.Header{"Retry-After": []string{when.Format(http.TimeFormat)}}}
+		got := retryAfterDelay(resp)
+		if got <= 0 || got > 5*time.Second {
+			t.Fatalf("retryAfterDelay = %s, want roughly 5s", got)
+		}
+	})
+
+	t.Run("absent", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{}}
+		if got := retryAfterDelay(resp); got != 0 {
+			t.Fatalf("retryAfterDelay = %s, want 0", got)
+		}
+	})
+
+	t.Run("nil response", func(t *testing.T) {
+		if got := retryAfterDelay(nil); got != 0 {
+			t.Fatalf("retryAfterDelay = %s, want 0", got)
+		}
+	})
+}
+
+func TestTokenBucketLimitsBurst(t *testing.T) {
+	bucket := newTokenBucket(10, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := bucket.wait(ctx); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Fatalf("burst of 2 tokens took %s, want near-immediate", elapsed)
+	}
+
+	start = time.Now()
+	if err := bucket.wait(ctx); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("request past the burst took %s, want to wait for refill at 10rps", elapsed)
+	}
+}
+
+func TestTokenBucketWaitRespectsContextCancellation(t *testing.T) {
+	bucket := newTokenBucket(1, 1)
+	if err := bucket.wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := bucket.wait(ctx); err == nil {
+		t.Fatal("expected wait to return an error once the context is canceled")
+	}
+}
@@ -0,0 +1,83 @@
+package httpman
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+)
+
+// FileField describes a single file part of a multipart/form-data body.
+type FileField struct {
+	// FieldName is the form field name for the file part.
+	FieldName string
+	// FileName is the filename reported in the part's Content-Disposition.
+	FileName string
+	// ContentType is the part's Content-Type. Defaults to
+	// application/octet-stream when empty.
+	ContentType string
+	// Reader is the file content. It is read, but not closed, by Body.
+	Reader io.Reader
+}
+
+// multipartBodyProvider streams a multipart/form-data body built from form
+// fields and files.
+type multipartBodyProvider struct {
+	fields   map[string]string
+	files    []FileField
+	boundary string
+}
+
+// newMultipartBodyProvider picks a boundary up front, since ContentType must
+// be available before Body is ever called.
+func newMultipartBodyProvider(fields map[string]string, files []FileField) *multipartBodyProvider {
+	return &multipartBodyProvider{
+		fields:   fields,
+		files:    files,
+		boundary: multipart.NewWriter(ioutil.Discard).Boundary(),
+	}
+}
+
+func (p *multipartBodyProvider) ContentType() string {
+	return "multipart/form-data; boundary=" + p.boundary
+}
+
+// Body streams the multipart body through an io.Pipe so files are written
+// straight onto the wire without buffering the whole request in memory.
+func (p *multipartBodyProvider) Body() (io.Reader, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	mw.SetBoundary(p.boundary)
+
+	go func() {
+		pw.CloseWithError(p.write(mw))
+	}()
+
+	return pr, nil
+}
+
+func (p *multipartBodyProvider) write(mw *multipart.Writer) error {
+	for key, value := range p.fields {
+		if err := mw.WriteField(key, value); err != nil {
+			return err
+		}
+	}
+	for _, f := range p.files {
+		ct := f.ContentType
+		if ct == "" {
+			ct = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, f.FieldName, f.FileName))
+		header.Set(contentType, ct)
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, f.Reader); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
@@ -1,11 +1,15 @@
 package httpman
 
 import (
+	"bytes"
+	"context"
 	goquery "github.com/google/go-querystring/query"
+	"google.golang.org/protobuf/proto"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 // Request
@@ -26,6 +30,28 @@ type Request struct {
 	queryMap map[string]string
 	// response decoder
 	responseDecoder ResponseDecoder
+	// getBody returns a fresh copy of a raw io.Reader body so that
+	// middleware such as Retry can replay it on subsequent attempts
+	getBody func() (io.ReadCloser, error)
+	// context for the request, set via WithContext or Timeout
+	ctx context.Context
+	// cancels ctx once the request completes, set by Timeout
+	cancel context.CancelFunc
+	// status codes accepted as successful by ExpectStatus; nil means
+	// unrestricted
+	expectedStatuses []int
+	// set by ErrorOn4xx5xx to reject 4xx/5xx responses not already allowed
+	// by expectedStatuses
+	errorOn4xx5xx bool
+	// per-status-range decoders registered via OnStatus
+	statusDecoders []statusRange
+	// Authenticator applied to this request, overriding the Httpman's
+	// default set via Httpman.Authenticator
+	authenticator Authenticator
+	// releaseBody returns a pooled body buffer obtained from bodyProvider in
+	// Send back to bufferPool once Do is done with the request, set only
+	// when a BodyJSON or BodyForm provider returned a pooled *bytes.Buffer
+	releaseBody func()
 }
 
 // initiates a new request with defaults
@@ -38,6 +64,7 @@ func (h *Httpman) NewRequest() *Request {
 		responseDecoder: jsonDecoder{},
 		method:          http.MethodGet,
 		absoluteURL:     h.baseURL,
+		ctx:             h.ctx,
 	}
 }
 
@@ -128,6 +155,38 @@ func (r *Request) AddQueryParam(key, value string) *Request {
 	return r
 }
 
+// Context
+
+// WithContext sets the context used for the request, replacing any context
+// derived via Timeout or inherited from the Httpman's WithContext. The
+// request is canceled if ctx is canceled or its deadline is exceeded.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if ctx != nil {
+		r.ctx = ctx
+		r.cancel = nil
+	}
+	return r
+}
+
+// Timeout derives a context with a deadline of d from the request's current
+// context and uses it for the request. The derived context is canceled once
+// the request completes via Do, freeing its resources.
+func (r *Request) Timeout(d time.Duration) *Request {
+	ctx, cancel := context.WithTimeout(r.context(), d)
+	r.ctx = ctx
+	r.cancel = cancel
+	return r
+}
+
+// context returns the context to use for the request, defaulting to
+// context.Background() when none was set via WithContext or Timeout.
+func (r *Request) context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
 // Body
 
 // Body sets the body. The body value will be set as the Body on new
@@ -140,6 +199,18 @@ func (r *Request) Body(body io.Reader) *Request {
 	return r.BodyProvider(&bodyProvider{body: body})
 }
 
+// GetBody sets a function returning a fresh copy of the request body on each
+// call, mirroring http.Request.GetBody. BodyJSON and BodyForm bodies are
+// already replayable without this, since net/http populates GetBody itself
+// for their *bytes.Buffer bodies; a raw io.Reader passed to
+// Body must set this explicitly to be retried by the Retry middleware.
+func (r *Request) GetBody(getBody func() (io.ReadCloser, error)) *Request {
+	if getBody != nil {
+		r.getBody = getBody
+	}
+	return r
+}
+
 // BodyProvider sets the body provider.
 func (r *Request) BodyProvider(body BodyProvider) *Request {
 	if body == nil {
@@ -182,17 +253,155 @@ func (r *Request) BodyForm(bodyForm interface{}) *Request {
 	return r.BodyProvider(&formBodyProvider{payload: bodyForm})
 }
 
+// BodyXML sets the bodyXML. The value pointed to by bodyXML will be XML
+// encoded as the Body on new requests.
+func (r *Request) BodyXML(bodyXML interface{}) *Request {
+	if bodyXML == nil {
+		return r
+	}
+	return r.BodyProvider(&codecBodyProvider{encoder: xmlEncoder{}, payload: bodyXML})
+}
+
+// BodyMsgpack sets the bodyMsgpack. The value pointed to by bodyMsgpack will
+// be MessagePack encoded as the Body on new requests.
+func (r *Request) BodyMsgpack(bodyMsgpack interface{}) *Request {
+	if bodyMsgpack == nil {
+		return r
+	}
+	return r.BodyProvider(&codecBodyProvider{encoder: msgpackEncoder{}, payload: bodyMsgpack})
+}
+
+// BodyProto sets the bodyProto. The proto.Message value will be protobuf
+// encoded as the Body on new requests.
+func (r *Request) BodyProto(bodyProto proto.Message) *Request {
+	if bodyProto == nil {
+		return r
+	}
+	return r.BodyProvider(&codecBodyProvider{encoder: protoEncoder{}, payload: bodyProto})
+}
+
+// BodyMultipart sets the body to a streamed multipart/form-data body built
+// from fields and files, for file upload requests. Files are streamed
+// through an io.Pipe rather than buffered in memory.
+func (r *Request) BodyMultipart(fields map[string]string, files []FileField) *Request {
+	return r.BodyProvider(newMultipartBodyProvider(fields, files))
+}
+
+// Decoder sets the ResponseDecoder used to decode the response Body,
+// overriding content negotiation against the Httpman's registered codecs.
+func (r *Request) Decoder(decoder ResponseDecoder) *Request {
+	if decoder != nil {
+		r.responseDecoder = decoder
+	}
+	return r
+}
+
+// Authenticator
+
+// Authenticator sets the Authenticator applied to this request, overriding
+// any default set via Httpman.Authenticator.
+func (r *Request) Authenticator(a Authenticator) *Request {
+	r.authenticator = a
+	return r
+}
+
+// authenticatorFor returns the Authenticator to apply to the request: one
+// set directly on the Request takes precedence over the Httpman's default.
+func (r *Request) authenticatorFor() Authenticator {
+	if r.authenticator != nil {
+		return r.authenticator
+	}
+	return r.httpmanInstance.authenticator
+}
+
+// Status
+
+// ExpectStatus restricts which status codes Do treats as successful; any
+// other status causes Do to return an *HTTPError instead of decoding the
+// response.
+func (r *Request) ExpectStatus(codes ...int) *Request {
+	if len(codes) > 0 {
+		r.expectedStatuses = codes
+	}
+	return r
+}
+
+// ErrorOn4xx5xx causes Do to return an *HTTPError for any 4xx or 5xx
+// response instead of decoding it into failureV, unless ExpectStatus has
+// already narrowed the accepted statuses.
+func (r *Request) ErrorOn4xx5xx() *Request {
+	r.errorOn4xx5xx = true
+	return r
+}
+
+// OnStatus registers decoder to decode responses whose status code falls in
+// [min, max], overriding the decoder Do would otherwise negotiate for that
+// response. Ranges are checked in the order they were added, so a narrower
+// range registered first takes priority, e.g.
+// req.OnStatus(200, 299, successDecoder).OnStatus(400, 499, problemDecoder).
+func (r *Request) OnStatus(min, max int, decoder ResponseDecoder) *Request {
+	if decoder != nil {
+		r.statusDecoders = append(r.statusDecoders, statusRange{min: min, max: max, decoder: decoder})
+	}
+	return r
+}
+
+// decoderForStatus returns the decoder registered via OnStatus for code, if
+// any.
+func (r *Request) decoderForStatus(code int) (ResponseDecoder, bool) {
+	for _, sr := range r.statusDecoders {
+		if sr.matches(code) {
+			return sr.decoder, true
+		}
+	}
+	return nil, false
+}
+
+// checkStatus returns an *HTTPError if resp's status code is rejected by
+// ExpectStatus/ErrorOn4xx5xx, reading the response Body into HTTPError.Body
+// since resp.Body will already be closed by the time Do returns.
+func (r *Request) checkStatus(resp *http.Response) error {
+	if !r.statusRejected(resp.StatusCode) {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       body,
+		Header:     resp.Header,
+	}
+}
+
+func (r *Request) statusRejected(code int) bool {
+	if r.expectedStatuses != nil {
+		for _, c := range r.expectedStatuses {
+			if c == code {
+				return false
+			}
+		}
+		return true
+	}
+	return r.errorOn4xx5xx && code >= 400
+}
+
 func (r *Request) Send() (*http.Request, error) {
 	reqURL, err := url.Parse(r.absoluteURL)
 	if err != nil {
 		return nil, err
 	}
 
+	queryStructs := r.queryStructs
 	if len(r.httpmanInstance.queryStructs) != 0 {
-		r.queryStructs = append(r.queryStructs, r.httpmanInstance.queryStructs...)
+		// merge into a local slice rather than appending onto r.queryStructs,
+		// since Send can be called repeatedly on the same Request (e.g. by
+		// SSE's reconnect loop) and must not grow it on every call
+		queryStructs = make([]interface{}, 0, len(r.queryStructs)+len(r.httpmanInstance.queryStructs))
+		queryStructs = append(queryStructs, r.queryStructs...)
+		queryStructs = append(queryStructs, r.httpmanInstance.queryStructs...)
 	}
 
-	err = addQueryStructs(reqURL, r.queryStructs)
+	err = addQueryStructs(reqURL, queryStructs)
 	if err != nil {
 		return nil, err
 	}
@@ -203,13 +412,31 @@ func (r *Request) Send() (*http.Request, error) {
 		if err != nil {
 			return nil, err
 		}
+		// only buffers taken from bufferPool go back to it; a *bytes.Buffer
+		// passed in by the caller via Body is left alone
+		switch r.bodyProvider.(type) {
+		case *jsonBodyProvider, *formBodyProvider:
+			if buf, ok := body.(*bytes.Buffer); ok {
+				r.releaseBody = func() { putBuffer(buf) }
+			}
+		}
 	}
-	req, err := http.NewRequest(r.method, reqURL.String(), body)
+	req, err := http.NewRequestWithContext(r.context(), r.method, reqURL.String(), body)
 	if err != nil {
 		return nil, err
 	}
+	if r.getBody != nil {
+		req.GetBody = r.getBody
+	}
 	addQueryMap(req, r.httpmanInstance.queryMap, r.queryMap)
 	addHeaders(req, r.httpmanInstance.header, r.header)
+
+	if auth := r.authenticatorFor(); auth != nil {
+		if err := auth.Apply(req); err != nil {
+			return nil, err
+		}
+	}
+
 	return req, err
 }
 
@@ -243,7 +470,13 @@ func (r *Request) Decode(successV, failureV interface{}) (*http.Response, error)
 // decoding is skipped. Any error sending the request or decoding the response
 // is returned.
 func (r *Request) Do(req *http.Request, successV, failureV interface{}) (*http.Response, error) {
-	resp, err := r.httpmanInstance.httpClient.Do(req)
+	if r.cancel != nil {
+		defer r.cancel()
+	}
+	if r.releaseBody != nil {
+		defer r.releaseBody()
+	}
+	resp, err := r.httpmanInstance.executor().Do(req)
 	if err != nil {
 		return resp, err
 	}
@@ -256,6 +489,10 @@ func (r *Request) Do(req *http.Request, successV, failureV interface{}) (*http.R
 	// See: https://golang.org/pkg/net/http/#Response
 	defer io.Copy(ioutil.Discard, resp.Body)
 
+	if err := r.checkStatus(resp); err != nil {
+		return resp, err
+	}
+
 	// Don't try to decode on 204s or Content-Length is 0
 	if resp.StatusCode == http.StatusNoContent || resp.ContentLength == 0 {
 		return resp, nil
@@ -263,11 +500,31 @@ func (r *Request) Do(req *http.Request, successV, failureV interface{}) (*http.R
 
 	// Decode from json
 	if successV != nil || failureV != nil {
-		err = decodeResponse(resp, r.responseDecoder, successV, failureV)
+		decoder := r.negotiatedDecoder(resp)
+		if custom, ok := r.decoderForStatus(resp.StatusCode); ok {
+			decoder = custom
+		}
+		err = decodeResponse(resp, decoder, successV, failureV)
 	}
 	return resp, err
 }
 
+// negotiatedDecoder returns the ResponseDecoder to use for resp: an
+// explicitly set Decoder takes precedence, otherwise the Httpman's
+// registered codecs are consulted by the response's Content-Type, falling
+// back to the default JSON decoder.
+func (r *Request) negotiatedDecoder(resp *http.Response) ResponseDecoder {
+	if _, isDefault := r.responseDecoder.(jsonDecoder); !isDefault {
+		return r.responseDecoder
+	}
+	if ct := resp.Header.Get(contentType); ct != "" {
+		if decoder, ok := r.httpmanInstance.decoderForContentType(ct); ok {
+			return decoder
+		}
+	}
+	return r.responseDecoder
+}
+
 // decodeResponse decodes response Body into the value pointed to by successV
 // if the response is a success (2XX) or into the value pointed to by failureV
 // otherwise. If the successV or failureV argument to decode into is nil,
@@ -0,0 +1,28 @@
+package httpman
+
+import "testing"
+
+type testQueryStruct struct {
+	Foo string `url:"foo"`
+}
+
+func TestSendDoesNotMutateRequestQueryStructs(t *testing.T) {
+	h := New("http://example.com")
+	h.AddQueryStruct(&testQueryStruct{Foo: "bar"})
+	req := h.NewRequest().Get("/path")
+
+	for i := 0; i < 3; i++ {
+		httpReq, err := req.Send()
+		if err != nil {
+			t.Fatalf("Send (call %d): %v", i, err)
+		}
+		got := httpReq.URL.Query()["foo"]
+		if len(got) != 1 || got[0] != "bar" {
+			t.Fatalf("Send (call %d): query foo = %v, want [bar]", i, got)
+		}
+	}
+
+	if n := len(req.queryStructs); n != 0 {
+		t.Fatalf("Request.queryStructs grew to %d entries across repeated Send calls, want 0", n)
+	}
+}
@@ -0,0 +1,211 @@
+package httpman
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// copyBufferPool pools []byte buffers used by SaveTo to copy a response body
+// without allocating a fresh buffer per call.
+var copyBufferPool = sync.Pool{
+	New: func() interface{} { return make([]byte, 32*1024) },
+}
+
+// Stream creates a new HTTP request and returns the response with its Body
+// left open and undrained, for callers that need to stream a download,
+// Server-Sent Events, or a large payload without buffering it in memory.
+// Unlike Decode, Stream neither decodes nor closes resp.Body; the caller
+// owns it and is responsible for closing it.
+func (r *Request) Stream() (*http.Response, error) {
+	req, err := r.Send()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.httpmanInstance.executor().Do(req)
+	if r.releaseBody != nil {
+		r.releaseBody()
+	}
+	if err != nil {
+		if r.cancel != nil {
+			r.cancel()
+		}
+		return resp, err
+	}
+	if r.cancel != nil {
+		resp.Body = cancelOnClose{resp.Body, r.cancel}
+	}
+	return resp, nil
+}
+
+// cancelOnClose wraps a response Body so that closing it also cancels the
+// context derived by Request.Timeout, freeing its resources once the
+// caller is done streaming the response.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// Bytes creates a new HTTP request and returns the full response body read
+// into memory, alongside the response.
+func (r *Request) Bytes() ([]byte, *http.Response, error) {
+	resp, err := r.Stream()
+	if err != nil {
+		return nil, resp, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	return b, resp, err
+}
+
+// String creates a new HTTP request and returns the full response body as a
+// string, alongside the response.
+func (r *Request) String() (string, *http.Response, error) {
+	b, resp, err := r.Bytes()
+	return string(b), resp, err
+}
+
+// SaveTo creates a new HTTP request and copies the response body to w using
+// a pooled buffer, without loading the whole body into memory. It returns
+// the number of bytes copied alongside the response.
+func (r *Request) SaveTo(w io.Writer) (int64, *http.Response, error) {
+	resp, err := r.Stream()
+	if err != nil {
+		return 0, resp, err
+	}
+	defer resp.Body.Close()
+
+	buf := copyBufferPool.Get().([]byte)
+	defer copyBufferPool.Put(buf)
+
+	n, err := io.CopyBuffer(w, resp.Body, buf)
+	return n, resp, err
+}
+
+// SSEEvent is a single Server-Sent Events frame parsed by Request.SSE.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// SSE streams the response as a text/event-stream built on top of Stream,
+// invoking handler for each parsed event. If the stream ends (EOF or a read
+// error), SSE reconnects automatically, sending the last received event's ID
+// as a Last-Event-ID header so the server can resume where it left off. SSE
+// stops and returns handler's error once handler returns a non-nil error, or
+// returns ctx.Err() once the request's context is done.
+func (r *Request) SSE(handler func(event SSEEvent) error) error {
+	// Stream wraps resp.Body in cancelOnClose, which cancels r.cancel (set
+	// by Timeout) once the body is closed. That's correct for a single
+	// Stream call, but SSE calls Stream repeatedly on the same Request for
+	// each reconnect: canceling the shared context after the first event's
+	// body closes would fail every subsequent reconnect with "context
+	// canceled". Detach cancel for the duration of the loop and invoke it
+	// once SSE itself returns, so Timeout still bounds the whole session.
+	cancel := r.cancel
+	r.cancel = nil
+	defer func() {
+		r.cancel = cancel
+		if cancel != nil {
+			cancel()
+		}
+	}()
+
+	var lastEventID string
+	for {
+		if lastEventID != "" {
+			r.SetHeader("Last-Event-ID", lastEventID)
+		}
+		resp, err := r.Stream()
+		if err != nil {
+			return err
+		}
+		stopped, err := scanSSE(resp.Body, &lastEventID, handler)
+		resp.Body.Close()
+		if stopped {
+			return err
+		}
+		select {
+		case <-r.context().Done():
+			return r.context().Err()
+		default:
+		}
+	}
+}
+
+// scanSSE reads text/event-stream frames from body, invoking handler for
+// each event and recording its id into *lastEventID for reconnection. It
+// returns (true, err) when handler asked to stop by returning err, or
+// (false, nil) once body reaches EOF (or a non-handler read error), so the
+// caller can reconnect.
+func scanSSE(body io.Reader, lastEventID *string, handler func(event SSEEvent) error) (stopped bool, err error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var event SSEEvent
+	var data strings.Builder
+
+	flush := func() error {
+		if data.Len() == 0 && event.Event == "" && event.ID == "" {
+			return nil
+		}
+		event.Data = strings.TrimSuffix(data.String(), "\n")
+		if event.ID != "" {
+			*lastEventID = event.ID
+		}
+		err := handler(event)
+		event, data = SSEEvent{}, strings.Builder{}
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := flush(); err != nil {
+				return true, err
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, ":"):
+			// comment line, ignored per the SSE spec
+		case strings.HasPrefix(line, "event:"):
+			event.Event = sseFieldValue(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(sseFieldValue(line, "data:"))
+		case strings.HasPrefix(line, "id:"):
+			event.ID = sseFieldValue(line, "id:")
+		case strings.HasPrefix(line, "retry:"):
+			if ms, err := strconv.Atoi(sseFieldValue(line, "retry:")); err == nil {
+				event.Retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return true, err
+	}
+	return false, nil
+}
+
+// sseFieldValue strips prefix and a single leading space from line, per the
+// SSE spec's field parsing rules.
+func sseFieldValue(line, prefix string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(line, prefix), " ")
+}
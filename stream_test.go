@@ -0,0 +1,84 @@
+package httpman
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanSSEParsesEvents(t *testing.T) {
+	input := "event: message\n" +
+		"id: 1\n" +
+		"data: line one\n" +
+		"data: line two\n" +
+		"retry: 2000\n" +
+		"\n" +
+		": this is a comment and is ignored\n" +
+		"data: second event\n" +
+		"\n"
+
+	var got []SSEEvent
+	var lastEventID string
+	stopped, err := scanSSE(strings.NewReader(input), &lastEventID, func(e SSEEvent) error {
+		got = append(got, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scanSSE: %v", err)
+	}
+	if stopped {
+		t.Fatal("scanSSE reported stopped on clean EOF")
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2", len(got))
+	}
+
+	first := got[0]
+	if first.Event != "message" || first.ID != "1" || first.Data != "line one\nline two" || first.Retry != 2*time.Second {
+		t.Fatalf("unexpected first event: %+v", first)
+	}
+	if lastEventID != "1" {
+		t.Fatalf("lastEventID = %q, want %q", lastEventID, "1")
+	}
+
+	second := got[1]
+	if second.Data != "second event" {
+		t.Fatalf("unexpected second event: %+v", second)
+	}
+}
+
+func TestScanSSEFlushesTrailingEventWithoutBlankLine(t *testing.T) {
+	input := "data: no trailing blank line"
+
+	var got []SSEEvent
+	var lastEventID string
+	if _, err := scanSSE(strings.NewReader(input), &lastEventID, func(e SSEEvent) error {
+		got = append(got, e)
+		return nil
+	}); err != nil {
+		t.Fatalf("scanSSE: %v", err)
+	}
+
+	if len(got) != 1 || got[0].Data != "no trailing blank line" {
+		t.Fatalf("got %+v, want one event with the trailing data", got)
+	}
+}
+
+func TestScanSSEStopsOnHandlerError(t *testing.T) {
+	input := "data: one\n\ndata: two\n\n"
+	wantErr := errors.New("boom")
+
+	calls := 0
+	var lastEventID string
+	stopped, err := scanSSE(strings.NewReader(input), &lastEventID, func(e SSEEvent) error {
+		calls++
+		return wantErr
+	})
+	if !stopped || err != wantErr {
+		t.Fatalf("scanSSE returned stopped=%v err=%v, want stopped=true err=%v", stopped, err, wantErr)
+	}
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1 since it should stop after the first error", calls)
+	}
+}